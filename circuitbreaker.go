@@ -5,8 +5,8 @@ See docs: https://docs.microsoft.com/en-us/azure/architecture/patterns/circuit-b
 package circuitbreaker
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -19,89 +19,41 @@ var (
 
 	ErrRequestDisabled = fmt.Errorf("requests are temporarily disabled by the circuit breaker")
 	ErrBlocked         = fmt.Errorf("circuit breaker is blocking all requests. Call Unblock() to unblock it")
+	ErrRecovering      = fmt.Errorf("circuit breaker is gradually recovering and rejected this request as part of the ramp")
+	ErrTooManyRequests = fmt.Errorf("circuit breaker is half-open and already handling its maximum number of probe requests")
 )
 
 type (
 	// RequestFunc type for the request executor
 	RequestFunc func() (interface{}, error)
+	// ContextRequestFunc is like RequestFunc but receives a context, so it can respect a deadline
+	// or cancellation propagated from the caller via ExecContext
+	ContextRequestFunc func(ctx context.Context) (interface{}, error)
 	// Option allows to extend default circuit breaker
 	Option func(*CircuitBreaker)
-
-	// Counter statistics counter
-	// TODO: set correct public/private
-	Counter struct {
-		failure     uint32
-		success     uint32
-		lastFail    time.Time
-		lastSuccess time.Time
-		resetPeriod time.Duration
-		sync.Mutex
-	}
 )
 
-// Fail increases the consecutive failure counter
-// if the last failure increase happened long ago,
-// counter for failure should be set to 1
-// as a correctness measure for rarely used services
-func (c *Counter) Fail() uint32 {
-	c.Lock()
-	defer c.Unlock()
-	if time.Since(c.lastFail) > c.resetPeriod {
-		c.failure = 0
-	}
-	c.lastFail = time.Now()
-	c.failure++
-	c.success = 0
-	return c.failure
-}
-
-// Success increases the consecutive success counter
-// if the last success increase happened long ago,
-// counter for success should be set to 1
-// as a correctness measure for rarely used services
-func (c *Counter) Success() uint32 {
-	c.Lock()
-	defer c.Unlock()
-	if time.Since(c.lastSuccess) > c.resetPeriod {
-		c.success = 0
-	}
-	c.lastSuccess = time.Now()
-	c.success++
-	c.failure = 0
-	return c.success
-}
-
-// Reset resets all stats
-func (c *Counter) Reset() {
-	c.Lock()
-	c.Unlock()
-	c.lastFail = time.Time{}
-	c.lastSuccess = time.Time{}
-	c.failure = 0
-	c.success = 0
-}
-
-// CircuitBreaker implements circuit breaker
+// CircuitBreaker implements circuit breaker. It is a thin wrapper around a Tracker: it calls fn,
+// classifies the result via IsSuccessful, and reports the outcome back to the tracker.
 type CircuitBreaker struct {
-	service          string
-	counter          *Counter
-	state            *State
-	logger           Logger
-	blocked          bool
-	failureThreshold uint32
-	successThreshold uint32
-	sync.Mutex
+	tracker        *Tracker
+	isSuccessfulFn func(error) bool
+	callTimeout    time.Duration
 }
 
 // New initializes default circuit breaker
 func New(service string, settings ...Option) *CircuitBreaker {
 	cb := &CircuitBreaker{
-		service:          service,
-		counter:          &Counter{},
-		state:            NewState(),
-		logger:           NoopLogger{},
-		failureThreshold: defaultFailureThreshold,
-		successThreshold: defaultSuccessThreshold,
+		tracker: &Tracker{
+			service:          service,
+			counter:          &Counter{resetPeriod: defaultCounterResetPeriod, lastCleared: time.Now()},
+			state:            NewState(defaultOpenStatusPeriod),
+			logger:           NoopLogger{},
+			metrics:          NoopMetricsCollector{},
+			failureThreshold: defaultFailureThreshold,
+			successThreshold: defaultSuccessThreshold,
+			createdAt:        time.Now(),
+		},
 	}
 
 	for _, opt := range settings {
@@ -115,7 +67,7 @@ func New(service string, settings ...Option) *CircuitBreaker {
 // circuit breaker will enter open state
 func WithFailureThreshold(t uint32) Option {
 	return func(cb *CircuitBreaker) {
-		cb.failureThreshold = t
+		cb.tracker.failureThreshold = t
 	}
 }
 
@@ -123,90 +75,193 @@ func WithFailureThreshold(t uint32) Option {
 // circuit breaker will enter Closed state from Half Open state
 func WithSuccessThreshold(t uint32) Option {
 	return func(cb *CircuitBreaker) {
-		cb.successThreshold = t
+		cb.tracker.successThreshold = t
 	}
 }
 
-// WithCounterResetPeriod defines the period after which counter will reset its failure/success counter
+// WithCounterResetPeriod defines the rolling interval after which the counter clears its counts
+// while the circuit breaker is closed
 func WithCounterResetPeriod(t time.Duration) Option {
 	return func(cb *CircuitBreaker) {
-		cb.counter.resetPeriod = t
+		cb.tracker.counter.resetPeriod = t
 	}
 }
 
 // WithOpenPeriod defines the period for which circuit breaker can stay in open state
 func WithOpenPeriod(t time.Duration) Option {
 	return func(cb *CircuitBreaker) {
-		cb.state.openPeriod = t
+		cb.tracker.state.openPeriod = t
 	}
 }
 
 // WithLogger allows to replace default no-op logger
 func WithLogger(l Logger) Option {
 	return func(cb *CircuitBreaker) {
-		cb.logger = l
+		cb.tracker.logger = l
 	}
 }
 
-// Exec is the wrapper for the request which encapsulates the circuit breaker logic
+// WithReadyToTrip overrides the decision of when the circuit breaker should transition from
+// closed to open. It is invoked with the current rolling Counts after every failed request; the
+// default reproduces the failureThreshold behaviour, tripping once ConsecutiveFailures exceeds it.
+// This allows percentage-based policies, e.g. tripping once Requests is large enough and
+// TotalFailures/Requests crosses a ratio.
+func WithReadyToTrip(f func(Counts) bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.readyToTripFn = f
+	}
+}
+
+// WithOnStateChange registers a callback invoked whenever the circuit breaker transitions between
+// states, including the implicit open->half-open transition that occurs once the open period
+// elapses. It fires at most once per actual transition, even under concurrent Exec calls, so it is
+// safe to use for emitting metrics, firing webhooks, or alerting on trip/recovery.
+func WithOnStateChange(f func(service string, from, to Status)) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.addOnChange(func(from, to Status, openFor time.Duration) {
+			f(cb.tracker.service, from, to)
+		})
+	}
+}
+
+// WithRecoveryDuration switches half-open from the default all-or-nothing gate to a linear traffic
+// ramp: admission probability grows from 0 at the start of half-open to 1.0 at 2*d, so a recovering
+// dependency sees gradually increasing load instead of the full flood at once, and is only at 50%
+// admission by the time d has elapsed. Requests rejected by the ramp return ErrRecovering. A
+// failure at any point during the ramp reopens the breaker and restarts the open timeout; once the
+// full duration d has elapsed, the next success closes it.
+func WithRecoveryDuration(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.recoveryDuration = d
+	}
+}
+
+// WithMaxHalfOpenRequests bounds how many probe requests are allowed to run concurrently while the
+// circuit breaker is half-open. Once that many are in flight, further calls are rejected with
+// ErrTooManyRequests instead of joining the probe, preventing a stampede of concurrent callers from
+// all passing through half-open and reopening the breaker on the first failure among them.
+func WithMaxHalfOpenRequests(n uint32) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.maxHalfOpenReqs = n
+	}
+}
+
+// WithMetricsCollector wires service activity into c: every completed request's outcome, the
+// latency of each one treated as a success, and every state transition (with how long the breaker
+// spent open, when the transition is leaving StatusOpen). Results discarded as stale by
+// Tracker.AfterRequest are not reported. See the metrics package for a ready-made Prometheus implementation.
+func WithMetricsCollector(c MetricsCollector) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.metrics = c
+		cb.tracker.addOnChange(func(from, to Status, openFor time.Duration) {
+			c.ObserveStateChange(cb.tracker.service, from, to, openFor)
+		})
+	}
+}
+
+// WithCallTimeout bounds each call made through ExecContext with a per-call deadline: ExecContext
+// derives a new context with this timeout and passes it to fn. ExecContext does not check the
+// deadline after fn returns: a fn that ignores cancellation and overruns the deadline has its
+// result and error classified exactly as it returned them.
+func WithCallTimeout(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.callTimeout = d
+	}
+}
+
+// WithIsSuccessful overrides how an error returned by RequestFunc is classified for the purpose of
+// the circuit breaker's counts. Returning true tells the circuit breaker to treat the call as a
+// success (e.g. for expected errors like HTTP 4xx or context.Canceled) even though fn returned an
+// error, excluding it from tripping the breaker. The error itself is still returned to the caller.
+// The default treats any non-nil error as a failure.
+func WithIsSuccessful(f func(error) bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.isSuccessfulFn = f
+	}
+}
+
+// WithInitialDelay grants the circuit breaker a grace period of d after New returns during which
+// Exec/ExecContext still calls fn and returns its result, but neither successes nor failures update
+// Counts or state, so a dependency that is still warming up (or a caller that hasn't finished its
+// own startup) can't trip the breaker before it has even seen real traffic.
+func WithInitialDelay(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.tracker.initialDelay = d
+	}
+}
+
+// Exec is the wrapper for the request which encapsulates the circuit breaker logic. If fn's error
+// is classified as a failure by IsSuccessful, res is discarded and only the error is returned;
+// otherwise res is returned alongside err, even when err is non-nil (e.g. a context.Canceled that
+// WithIsSuccessful chose to classify as a success).
 func (cb *CircuitBreaker) Exec(fn RequestFunc) (interface{}, error) {
-	if cb.blocked {
-		return nil, ErrBlocked
-	}
-
-	switch cb.state.Status() {
-	case StatusClosed:
-		res, err := fn()
-		if err != nil {
-			cb.handleError(err)
-			return nil, err
-		}
-		cb.counter.Success()
-		return res, nil
-	case StatusHalfOpen:
-		// half open is intermediate state, where any failure will set back the circuitbreaker into open state
-		// if required number of success responses are received circuitbreaker goes back to the closed state
-		res, err := fn()
-		if err != nil {
-			cb.handleError(err)
-			return nil, err
-		}
-		if cb.counter.Success() > cb.successThreshold {
-			cb.state.Set(StatusClosed)
-		}
-		return res, nil
-	case StatusOpen:
-		return nil, ErrRequestDisabled
-	}
-	return nil, nil
-}
-
-func (cb *CircuitBreaker) handleError(err error) {
-	cb.logger.Error("request failed with ", err)
-	failed := cb.counter.Fail()
-	if failed > cb.failureThreshold || cb.state.Status() == StatusHalfOpen {
-		cb.logger.Info("entering open state for ", cb.state.openPeriod)
-		cb.state.Set(StatusOpen)
+	generation, err := cb.tracker.BeforeRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := fn()
+	success := cb.isSuccessful(err)
+	cb.tracker.AfterRequest(generation, success, time.Since(start))
+	if !success {
+		return nil, err
+	}
+	return res, err
+}
+
+// ExecContext is the context-aware counterpart to Exec. It rejects the call immediately if ctx is
+// already done, otherwise propagates ctx into fn (bounded by WithCallTimeout, if configured) and
+// runs it through the same circuit breaker logic as Exec. Timeout and cancellation errors flow
+// through IsSuccessful like any other error, so callers can exclude context.Canceled or a call
+// timeout from tripping the breaker via WithIsSuccessful.
+func (cb *CircuitBreaker) ExecContext(ctx context.Context, fn ContextRequestFunc) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cb.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cb.callTimeout)
+		defer cancel()
+	}
+
+	return cb.Exec(func() (interface{}, error) {
+		return fn(ctx)
+	})
+}
+
+func (cb *CircuitBreaker) isSuccessful(err error) bool {
+	if cb.isSuccessfulFn != nil {
+		return cb.isSuccessfulFn(err)
 	}
+	return err == nil
 }
 
 // Reset allows to reset the state to the defaults
 func (cb *CircuitBreaker) Reset() {
-	cb.counter.Reset()
-	cb.state.Reset()
-	cb.Unblock()
+	cb.tracker.Reset()
 }
 
 // Block blocks all requests
 func (cb *CircuitBreaker) Block() {
-	cb.Lock()
-	cb.blocked = true
-	cb.Unlock()
+	cb.tracker.Block()
 }
 
 // Unblock returns circuitbreaker to the normal operation mode
 func (cb *CircuitBreaker) Unblock() {
-	cb.Lock()
-	cb.blocked = false
-	cb.Unlock()
+	cb.tracker.Unblock()
+}
+
+// Activate resumes counting towards trip decisions after a prior Deactivate call. It has no effect
+// on Block/Unblock, which reject requests outright rather than merely pausing the trip decision.
+func (cb *CircuitBreaker) Activate() {
+	cb.tracker.Activate()
+}
+
+// Deactivate suspends counting towards trip decisions, e.g. during a deploy or migration window
+// known to cause transient errors. Exec still calls fn and returns its result; neither successes
+// nor failures influence Counts or state while deactivated.
+func (cb *CircuitBreaker) Deactivate() {
+	cb.tracker.Deactivate()
 }
@@ -0,0 +1,27 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCircuitBreaker_Exec_ReturnsResultOnClassifiedSuccess verifies the chunk0-6 guarantee that
+// Exec returns fn's result alongside its error whenever WithIsSuccessful classifies that error as
+// a success, matching v2's contract instead of discarding res whenever err is non-nil.
+func TestCircuitBreaker_Exec_ReturnsResultOnClassifiedSuccess(t *testing.T) {
+	errCanceled := errors.New("canceled")
+	cb := New("svc", WithIsSuccessful(func(err error) bool {
+		return errors.Is(err, errCanceled)
+	}))
+
+	res, err := cb.Exec(func() (interface{}, error) {
+		return "partial result", errCanceled
+	})
+
+	if res != "partial result" {
+		t.Fatalf("expected classified-success result to be returned, got %v", res)
+	}
+	if !errors.Is(err, errCanceled) {
+		t.Fatalf("expected the original error to still be returned, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package circuitbreaker
+
+import "time"
+
+// MetricsCollector receives circuit breaker activity for observability backends (e.g. Prometheus)
+// to record. Implementations must be safe for concurrent use. See the metrics package for a
+// ready-made Prometheus implementation.
+type MetricsCollector interface {
+	// ObserveRequest is called once per completed request with whether it was treated as a success;
+	// results discarded as stale by Tracker.AfterRequest are not reported
+	ObserveRequest(service string, success bool)
+	// ObserveLatency is called with the duration of each reported request treated as a success
+	ObserveLatency(service string, d time.Duration)
+	// ObserveStateChange is called on every state transition; openFor holds how long the breaker
+	// stayed open when the transition is leaving StatusOpen, and is zero otherwise
+	ObserveStateChange(service string, from, to Status, openFor time.Duration)
+}
+
+// NoopMetricsCollector is the default MetricsCollector; it does not record anything
+type NoopMetricsCollector struct{}
+
+// ObserveRequest ...
+func (NoopMetricsCollector) ObserveRequest(service string, success bool) {}
+
+// ObserveLatency ...
+func (NoopMetricsCollector) ObserveLatency(service string, d time.Duration) {}
+
+// ObserveStateChange ...
+func (NoopMetricsCollector) ObserveStateChange(service string, from, to Status, openFor time.Duration) {
+}
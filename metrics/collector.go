@@ -0,0 +1,74 @@
+/*
+Package metrics provides a Prometheus-backed circuitbreaker.MetricsCollector, recording request
+outcomes, current state, state transitions, open-state duration and success latency per service.
+Wire it in with circuitbreaker.WithMetricsCollector.
+*/
+package metrics
+
+import (
+	"time"
+
+	circuitbreaker "github.com/ideahitme/circuit-breaker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector implements circuitbreaker.MetricsCollector on top of Prometheus client metrics
+type Collector struct {
+	requestsTotal    *prometheus.CounterVec
+	state            *prometheus.GaugeVec
+	stateTransitions *prometheus.CounterVec
+	openDuration     *prometheus.HistogramVec
+	successLatency   *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector and registers its metrics with reg
+func NewCollector(reg prometheus.Registerer) *Collector {
+	c := &Collector{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "circuitbreaker_requests_total",
+			Help: "Total requests executed through the circuit breaker, by result.",
+		}, []string{"service", "result"}),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "circuitbreaker_state",
+			Help: "Current circuit breaker state: 0=closed, 1=open, 2=half-open.",
+		}, []string{"service"}),
+		stateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "circuitbreaker_state_transitions_total",
+			Help: "Total circuit breaker state transitions, by origin and destination state.",
+		}, []string{"service", "from", "to"}),
+		openDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "circuitbreaker_open_duration_seconds",
+			Help: "How long the circuit breaker stayed open before leaving the open state.",
+		}, []string{"service"}),
+		successLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "circuitbreaker_success_latency_seconds",
+			Help: "Latency of requests executed through the circuit breaker that were treated as successful.",
+		}, []string{"service"}),
+	}
+
+	reg.MustRegister(c.requestsTotal, c.state, c.stateTransitions, c.openDuration, c.successLatency)
+	return c
+}
+
+// ObserveRequest implements circuitbreaker.MetricsCollector
+func (c *Collector) ObserveRequest(service string, success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	c.requestsTotal.WithLabelValues(service, result).Inc()
+}
+
+// ObserveLatency implements circuitbreaker.MetricsCollector
+func (c *Collector) ObserveLatency(service string, d time.Duration) {
+	c.successLatency.WithLabelValues(service).Observe(d.Seconds())
+}
+
+// ObserveStateChange implements circuitbreaker.MetricsCollector
+func (c *Collector) ObserveStateChange(service string, from, to circuitbreaker.Status, openFor time.Duration) {
+	c.stateTransitions.WithLabelValues(service, from.String(), to.String()).Inc()
+	c.state.WithLabelValues(service).Set(float64(to))
+	if from == circuitbreaker.StatusOpen {
+		c.openDuration.WithLabelValues(service).Observe(openFor.Seconds())
+	}
+}
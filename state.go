@@ -34,16 +34,21 @@ func (s Status) String() string {
 
 // State indicates the state of the circuitbreaker
 type State struct {
-	lastOpen   time.Time
-	openPeriod time.Duration
-	status     Status
+	lastOpen     time.Time
+	lastHalfOpen time.Time
+	openPeriod   time.Duration
+	status       Status
+	generation   uint64
+	onChange     func(from, to Status, openFor time.Duration)
 	sync.Mutex
 }
 
-// NewState initializes new State object
-func NewState() *State {
+// NewState initializes new State object with the given open period: how long the state stays
+// open before StatusAndGeneration implicitly moves it to half-open.
+func NewState(openPeriod time.Duration) *State {
 	return &State{
-		status: StatusClosed,
+		status:     StatusClosed,
+		openPeriod: openPeriod,
 	}
 }
 
@@ -51,15 +56,27 @@ func NewState() *State {
 // it handles the special case when circuit breaker has set to open state
 // far too long ago
 func (s *State) Status() Status {
+	status, _ := s.StatusAndGeneration()
+	return status
+}
+
+// StatusAndGeneration returns the current status together with its generation: a counter bumped
+// on every transition, including the implicit open-to-half-open transition below. Tracker uses the
+// generation to detect and discard stale results that arrive after the breaker has already moved
+// on to a new state.
+func (s *State) StatusAndGeneration() (Status, uint64) {
 	s.Lock()
-	defer s.Unlock()
 	// check when state last entered open
-	if s.status == StatusOpen {
-		if time.Since(s.lastOpen) > s.openPeriod {
-			s.status = StatusHalfOpen
-		}
+	var fire func()
+	if s.status == StatusOpen && time.Since(s.lastOpen) > s.openPeriod {
+		fire = s.transition(StatusHalfOpen)
+	}
+	status, generation := s.status, s.generation
+	s.Unlock()
+	if fire != nil {
+		fire()
 	}
-	return s.status
+	return status, generation
 }
 
 // Set updates the status of the state
@@ -67,20 +84,58 @@ func (s *State) Status() Status {
 // last entered open state
 func (s *State) Set(status Status) {
 	s.Lock()
-	defer s.Unlock()
-	// update lastOpen field
-	if status == StatusOpen {
+	fire := s.transition(status)
+	s.Unlock()
+	fire()
+}
+
+// transition moves the state to status, updating the lastOpen bookkeeping field and returning a
+// closure that fires onChange exactly once if the status actually changes. It is the single path
+// through which the status field is mutated, including the implicit open-to-half-open transition
+// inside StatusAndGeneration, so that onChange can never be skipped or double-fired under
+// concurrent callers.
+// Callers must hold s.Mutex while calling transition, but must release it before invoking the
+// returned closure: onChange is arbitrary user code (e.g. a webhook or a MetricsCollector call)
+// and must never run while s.Mutex is held, or it would stall every other goroutine's Exec call
+// for as long as it takes to run.
+func (s *State) transition(status Status) func() {
+	if s.status == status {
+		return func() {}
+	}
+	from := s.status
+	var openFor time.Duration
+	if from == StatusOpen {
+		openFor = time.Since(s.lastOpen)
+	}
+	switch status {
+	case StatusOpen:
 		s.lastOpen = time.Now()
+	case StatusHalfOpen:
+		s.lastHalfOpen = time.Now()
 	}
-
 	s.status = status
+	s.generation++
+	onChange := s.onChange
+	return func() {
+		if onChange != nil {
+			onChange(from, status, openFor)
+		}
+	}
 }
 
-// Reset resets all flags
-func (s *State) Reset() {
+// HalfOpenSince returns the time at which the breaker last transitioned into half-open state. It
+// is used by the optional recovery-ramp mode to compute admission probability.
+func (s *State) HalfOpenSince() time.Time {
 	s.Lock()
 	defer s.Unlock()
+	return s.lastHalfOpen
+}
 
-	s.status = StatusClosed
+// Reset resets all flags
+func (s *State) Reset() {
+	s.Lock()
+	fire := s.transition(StatusClosed)
 	s.lastOpen = time.Now().Add(-24 * time.Hour)
+	s.Unlock()
+	fire()
 }
@@ -0,0 +1,63 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestState_OnChangeFiresExactlyOnce verifies the chunk0-3 guarantee that onChange fires at most
+// once per actual transition, even when many goroutines race to apply the same transition
+// concurrently.
+func TestState_OnChangeFiresExactlyOnce(t *testing.T) {
+	s := NewState(time.Minute)
+	var calls int32
+	s.onChange = func(from, to Status, openFor time.Duration) {
+		atomic.AddInt32(&calls, 1)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Set(StatusOpen)
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected onChange to fire exactly once, got %d", got)
+	}
+}
+
+// TestState_OnChangeDoesNotBlockOtherCallers guards against a slow onChange callback (e.g. a
+// webhook or a MetricsCollector call) stalling unrelated callers trying to read the current
+// status: transition must release s.Mutex before invoking onChange.
+func TestState_OnChangeDoesNotBlockOtherCallers(t *testing.T) {
+	s := NewState(time.Minute)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	s.onChange = func(from, to Status, openFor time.Duration) {
+		close(entered)
+		<-release
+	}
+
+	go s.Set(StatusOpen)
+	<-entered // onChange is now running and blocked on release
+
+	done := make(chan struct{})
+	go func() {
+		s.StatusAndGeneration()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StatusAndGeneration blocked while onChange callback was still running")
+	}
+
+	close(release)
+}
@@ -0,0 +1,330 @@
+package circuitbreaker
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Counts holds the rolling request statistics consulted by ReadyToTrip to decide whether
+	// the circuit breaker should open
+	Counts struct {
+		Requests             uint32
+		TotalSuccesses       uint32
+		TotalFailures        uint32
+		ConsecutiveSuccesses uint32
+		ConsecutiveFailures  uint32
+	}
+
+	// Counter tracks rolling Counts for a circuit breaker. Counts are cleared every resetPeriod
+	// while the breaker is closed, and whenever Reset is called explicitly (e.g. on a state
+	// transition), so stale data from a previous window never influences the next trip decision
+	Counter struct {
+		counts      Counts
+		resetPeriod time.Duration
+		lastCleared time.Time
+		sync.Mutex
+	}
+)
+
+func (c *Counts) onRequest() {
+	c.Requests++
+}
+
+func (c *Counts) onSuccess() {
+	c.TotalSuccesses++
+	c.ConsecutiveSuccesses++
+	c.ConsecutiveFailures = 0
+}
+
+func (c *Counts) onFailure() {
+	c.TotalFailures++
+	c.ConsecutiveFailures++
+	c.ConsecutiveSuccesses = 0
+}
+
+func (c *Counts) clear() {
+	c.Requests = 0
+	c.TotalSuccesses = 0
+	c.TotalFailures = 0
+	c.ConsecutiveSuccesses = 0
+	c.ConsecutiveFailures = 0
+}
+
+// Counts returns a snapshot of the current rolling counts
+func (c *Counter) Counts() Counts {
+	c.Lock()
+	defer c.Unlock()
+	return c.counts
+}
+
+// tick clears the counts once resetPeriod has elapsed since they were last cleared; it is only
+// called while the breaker is closed, per the rolling window semantics
+func (c *Counter) tick() {
+	c.Lock()
+	defer c.Unlock()
+	if c.resetPeriod > 0 && time.Since(c.lastCleared) > c.resetPeriod {
+		c.counts.clear()
+		c.lastCleared = time.Now()
+	}
+}
+
+func (c *Counter) onRequest() {
+	c.Lock()
+	defer c.Unlock()
+	c.counts.onRequest()
+}
+
+func (c *Counter) onSuccess() Counts {
+	c.Lock()
+	defer c.Unlock()
+	c.counts.onSuccess()
+	return c.counts
+}
+
+func (c *Counter) onFailure() Counts {
+	c.Lock()
+	defer c.Unlock()
+	c.counts.onFailure()
+	return c.counts
+}
+
+// Reset clears all counts and restarts the rolling window
+func (c *Counter) Reset() {
+	c.Lock()
+	defer c.Unlock()
+	c.counts.clear()
+	c.lastCleared = time.Now()
+}
+
+// Tracker implements the circuit breaker's counting and state-machine logic, independent of how a
+// call is actually made. CircuitBreaker.Exec is a thin wrapper around a Tracker; code that owns its
+// own request loop and can't produce a RequestFunc (a pooled connection, a streaming gRPC call, a
+// database driver) can drive a Tracker directly with BeforeRequest/AfterRequest instead.
+type Tracker struct {
+	service          string
+	counter          *Counter
+	state            *State
+	logger           Logger
+	metrics          MetricsCollector
+	blocked          bool
+	failureThreshold uint32
+	successThreshold uint32
+	readyToTripFn    func(Counts) bool
+	recoveryDuration time.Duration
+	maxHalfOpenReqs  uint32
+	halfOpenReqs     uint32 // atomically incremented/decremented; do not access without atomic ops
+	createdAt        time.Time
+	initialDelay     time.Duration
+	deactivated      bool
+	sync.Mutex
+}
+
+// NewTracker builds a standalone Tracker configured with the same Option values accepted by New.
+// Options that only make sense around a RequestFunc call (WithIsSuccessful, WithCallTimeout) have
+// no effect here, since a Tracker never calls one.
+func NewTracker(service string, settings ...Option) *Tracker {
+	return New(service, settings...).tracker
+}
+
+// BeforeRequest checks whether a call should be allowed to proceed given the tracker's current
+// state (blocked, open, half-open ramp/probe limits), and if so returns the generation to pass to
+// the matching AfterRequest call once the caller's request completes.
+func (t *Tracker) BeforeRequest() (uint64, error) {
+	t.Lock()
+	blocked := t.blocked
+	t.Unlock()
+	if blocked {
+		return 0, ErrBlocked
+	}
+
+	status, generation := t.state.StatusAndGeneration()
+
+	switch status {
+	case StatusOpen:
+		return generation, ErrRequestDisabled
+	case StatusHalfOpen:
+		if t.recoveryDuration > 0 && !t.admitRamp() {
+			return generation, ErrRecovering
+		}
+		if !t.acquireHalfOpenSlot() {
+			return generation, ErrTooManyRequests
+		}
+	case StatusClosed:
+		t.counter.tick()
+	}
+
+	return generation, nil
+}
+
+// AfterRequest records the outcome of a call started under generation (as returned by
+// BeforeRequest), including its latency if it succeeded, and decides whether the breaker should
+// change state. If the tracker has since moved on to a new generation, the result is stale - e.g.
+// a half-open probe whose result arrives after the breaker already reopened - and is discarded in
+// its entirety, including the metrics it would otherwise have reported, so that ObserveRequest and
+// ObserveLatency always agree on what counts as "observed".
+func (t *Tracker) AfterRequest(generation uint64, success bool, latency time.Duration) {
+	status, curGeneration := t.state.StatusAndGeneration()
+	if generation != curGeneration {
+		return
+	}
+	if status == StatusHalfOpen {
+		defer t.releaseHalfOpenSlot()
+	}
+
+	t.metrics.ObserveRequest(t.service, success)
+	if success {
+		t.metrics.ObserveLatency(t.service, latency)
+	}
+	if !t.isTracking() {
+		return
+	}
+
+	t.counter.onRequest()
+	if success {
+		t.onSuccess(status)
+		return
+	}
+	t.onFailure(status)
+}
+
+// isTracking reports whether the tracker should currently be counting requests towards a trip
+// decision: false during the initial delay window after construction, and false while explicitly
+// suspended via Deactivate.
+func (t *Tracker) isTracking() bool {
+	t.Lock()
+	deactivated := t.deactivated
+	t.Unlock()
+	if deactivated {
+		return false
+	}
+	return time.Since(t.createdAt) >= t.initialDelay
+}
+
+// Activate resumes counting towards trip decisions after a prior Deactivate call. It has no effect
+// on Block/Unblock, which reject requests outright rather than merely pausing the trip decision.
+func (t *Tracker) Activate() {
+	t.Lock()
+	t.deactivated = false
+	t.Unlock()
+}
+
+// Deactivate suspends counting towards trip decisions, e.g. during a deploy or migration window
+// known to cause transient errors. Exec still calls fn and returns its result; neither successes
+// nor failures influence Counts or state while deactivated.
+func (t *Tracker) Deactivate() {
+	t.Lock()
+	t.deactivated = true
+	t.Unlock()
+}
+
+func (t *Tracker) readyToTrip(counts Counts) bool {
+	if t.readyToTripFn != nil {
+		return t.readyToTripFn(counts)
+	}
+	return counts.ConsecutiveFailures > t.failureThreshold
+}
+
+func (t *Tracker) onSuccess(status Status) {
+	counts := t.counter.onSuccess()
+	if status != StatusHalfOpen {
+		return
+	}
+	if t.recoveryDuration > 0 {
+		if time.Since(t.state.HalfOpenSince()) >= t.recoveryDuration {
+			t.state.Set(StatusClosed)
+			t.counter.Reset()
+		}
+		return
+	}
+	if counts.ConsecutiveSuccesses > t.successThreshold {
+		t.state.Set(StatusClosed)
+		t.counter.Reset()
+	}
+}
+
+func (t *Tracker) onFailure(status Status) {
+	t.logger.Error("request marked as failed")
+	counts := t.counter.onFailure()
+	if status == StatusHalfOpen {
+		t.logger.Info("entering open state for ", t.state.openPeriod)
+		t.state.Set(StatusOpen)
+		t.counter.Reset()
+		return
+	}
+	if t.readyToTrip(counts) {
+		t.logger.Info("entering open state for ", t.state.openPeriod)
+		t.state.Set(StatusOpen)
+		t.counter.Reset()
+	}
+}
+
+// acquireHalfOpenSlot reserves one of the maxHalfOpenReqs probe slots, if that bound is configured.
+func (t *Tracker) acquireHalfOpenSlot() bool {
+	if t.maxHalfOpenReqs == 0 {
+		return true
+	}
+	if atomic.AddUint32(&t.halfOpenReqs, 1) > t.maxHalfOpenReqs {
+		atomic.AddUint32(&t.halfOpenReqs, ^uint32(0))
+		return false
+	}
+	return true
+}
+
+// releaseHalfOpenSlot frees a probe slot reserved by acquireHalfOpenSlot.
+func (t *Tracker) releaseHalfOpenSlot() {
+	if t.maxHalfOpenReqs == 0 {
+		return
+	}
+	atomic.AddUint32(&t.halfOpenReqs, ^uint32(0))
+}
+
+// admitRamp decides whether a half-open request is let through during the optional recovery ramp:
+// admission probability increases linearly from 0 at the start of half-open to 1.0 at
+// 2*recoveryDuration, i.e. it only reaches 50% by the time recoveryDuration has elapsed and
+// onSuccess is willing to close the breaker, capped at 1.0 thereafter.
+func (t *Tracker) admitRamp() bool {
+	elapsed := time.Since(t.state.HalfOpenSince())
+	ratio := 0.5 * (float64(elapsed) / float64(t.recoveryDuration))
+	if ratio > 1.0 {
+		ratio = 1.0
+	}
+	return rand.Float64() < ratio
+}
+
+// addOnChange appends f to the chain of callbacks State.transition invokes, so that
+// WithOnStateChange and WithMetricsCollector can both be registered without one clobbering the
+// other.
+func (t *Tracker) addOnChange(f func(from, to Status, openFor time.Duration)) {
+	prev := t.state.onChange
+	t.state.onChange = func(from, to Status, openFor time.Duration) {
+		if prev != nil {
+			prev(from, to, openFor)
+		}
+		f(from, to, openFor)
+	}
+}
+
+// Reset clears the tracker back to its defaults: empty counts, closed state, and unblocked
+func (t *Tracker) Reset() {
+	t.counter.Reset()
+	t.state.Reset()
+	atomic.StoreUint32(&t.halfOpenReqs, 0)
+	t.Unblock()
+}
+
+// Block blocks all requests
+func (t *Tracker) Block() {
+	t.Lock()
+	t.blocked = true
+	t.Unlock()
+}
+
+// Unblock returns the tracker to normal operation
+func (t *Tracker) Unblock() {
+	t.Lock()
+	t.blocked = false
+	t.Unlock()
+}
@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTracker_AfterRequest_DiscardsStaleGeneration verifies the chunk0-8 guarantee that a result
+// reported under a generation older than the tracker's current one is discarded instead of
+// corrupting the counts of the state the tracker has since moved on to.
+func TestTracker_AfterRequest_DiscardsStaleGeneration(t *testing.T) {
+	tr := NewTracker("svc", WithFailureThreshold(0))
+
+	generation, err := tr.BeforeRequest()
+	if err != nil {
+		t.Fatalf("unexpected error from BeforeRequest: %v", err)
+	}
+
+	// Force a transition so the tracker moves to a new generation behind the caller's back,
+	// simulating a concurrent request that reported a failure first.
+	tr.state.Set(StatusOpen)
+
+	tr.AfterRequest(generation, true, time.Millisecond)
+
+	if got := tr.counter.Counts(); got.Requests != 0 {
+		t.Fatalf("expected stale AfterRequest to be discarded, got counts %+v", got)
+	}
+}
+
+// TestTracker_MaxHalfOpenRequests_ReleasesSlotExactlyOnce verifies the chunk0-5 guarantee that a
+// half-open probe slot is released exactly once, even when the same request that resolves
+// half-open (closing or reopening the breaker) also triggers the deferred releaseHalfOpenSlot in
+// AfterRequest. Double-releasing would underflow the uint32 probe counter.
+func TestTracker_MaxHalfOpenRequests_ReleasesSlotExactlyOnce(t *testing.T) {
+	tr := NewTracker("svc", WithMaxHalfOpenRequests(2), WithSuccessThreshold(0))
+	tr.state.Set(StatusHalfOpen)
+
+	generation, err := tr.BeforeRequest()
+	if err != nil {
+		t.Fatalf("unexpected error from BeforeRequest: %v", err)
+	}
+
+	tr.AfterRequest(generation, true, time.Millisecond)
+
+	if got := atomic.LoadUint32(&tr.halfOpenReqs); got != 0 {
+		t.Fatalf("expected halfOpenReqs to settle at 0, got %d", got)
+	}
+}
+
+// fakeMetricsCollector records how many times each MetricsCollector method was called, so tests
+// can assert ObserveRequest and ObserveLatency stay in lockstep.
+type fakeMetricsCollector struct {
+	requests int
+	latency  int
+}
+
+func (f *fakeMetricsCollector) ObserveRequest(service string, success bool)    { f.requests++ }
+func (f *fakeMetricsCollector) ObserveLatency(service string, d time.Duration) { f.latency++ }
+func (f *fakeMetricsCollector) ObserveStateChange(service string, from, to Status, openFor time.Duration) {
+}
+
+// TestTracker_AfterRequest_StaleGenerationSkipsMetrics verifies the chunk0-7 guarantee that a
+// stale-generation result is discarded before either metric is recorded, so ObserveRequest and
+// ObserveLatency never disagree about what was observed.
+func TestTracker_AfterRequest_StaleGenerationSkipsMetrics(t *testing.T) {
+	metrics := &fakeMetricsCollector{}
+	tr := NewTracker("svc", WithFailureThreshold(0))
+	tr.metrics = metrics
+
+	generation, err := tr.BeforeRequest()
+	if err != nil {
+		t.Fatalf("unexpected error from BeforeRequest: %v", err)
+	}
+
+	tr.state.Set(StatusOpen)
+	tr.AfterRequest(generation, true, time.Millisecond)
+
+	if metrics.requests != 0 || metrics.latency != 0 {
+		t.Fatalf("expected stale AfterRequest to record no metrics, got requests=%d latency=%d", metrics.requests, metrics.latency)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Package v2 implements the same 3-state proxy as the top-level circuitbreaker
+package, but uses generics so that callers no longer have to round-trip
+their response through interface{} and type-assert it back out of Exec.
+
+v2 is not kept in parity with the top-level package as that one gains
+features: as of this writing it lacks ReadyToTrip/rolling Counts,
+OnStateChange hooks, RecoveryDuration, MaxHalfOpenRequests, a
+MetricsCollector, a standalone Tracker, and InitialDelay/Activate/Deactivate.
+Prefer the top-level circuitbreaker package unless the interface{} round-trip
+it requires is a real problem for your caller.
+See docs: https://docs.microsoft.com/en-us/azure/architecture/patterns/circuit-breaker
+*/
+package v2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaults
+var (
+	defaultOpenStatusPeriod   = 1 * time.Minute
+	defaultSuccessThreshold   = uint32(5) // number of consecutive success to transition from half-open to closed state
+	defaultFailureThreshold   = uint32(5) // number of consecutive failures to transition from closed to open state
+	defaultCounterResetPeriod = 1 * time.Minute
+
+	ErrRequestDisabled = fmt.Errorf("requests are temporarily disabled by the circuit breaker")
+	ErrBlocked         = fmt.Errorf("circuit breaker is blocking all requests. Call Unblock() to unblock it")
+)
+
+type (
+	// RequestFunc type for the request executor
+	RequestFunc[T any] func() (T, error)
+	// ContextRequestFunc is like RequestFunc but receives a context, so it can respect a deadline
+	// or cancellation propagated from the caller via ExecContext
+	ContextRequestFunc[T any] func(ctx context.Context) (T, error)
+	// Option allows to extend default circuit breaker
+	Option[T any] func(*CircuitBreaker[T])
+
+	// Counter statistics counter
+	Counter struct {
+		failure     uint32
+		success     uint32
+		lastFail    time.Time
+		lastSuccess time.Time
+		resetPeriod time.Duration
+		sync.Mutex
+	}
+)
+
+// Fail increases the consecutive failure counter
+// if the last failure increase happened long ago,
+// counter for failure should be set to 1
+// as a correctness measure for rarely used services
+func (c *Counter) Fail() uint32 {
+	c.Lock()
+	defer c.Unlock()
+	if time.Since(c.lastFail) > c.resetPeriod {
+		c.failure = 0
+	}
+	c.lastFail = time.Now()
+	c.failure++
+	c.success = 0
+	return c.failure
+}
+
+// Success increases the consecutive success counter
+// if the last success increase happened long ago,
+// counter for success should be set to 1
+// as a correctness measure for rarely used services
+func (c *Counter) Success() uint32 {
+	c.Lock()
+	defer c.Unlock()
+	if time.Since(c.lastSuccess) > c.resetPeriod {
+		c.success = 0
+	}
+	c.lastSuccess = time.Now()
+	c.success++
+	c.failure = 0
+	return c.success
+}
+
+// Reset resets all stats
+func (c *Counter) Reset() {
+	c.Lock()
+	c.Unlock()
+	c.lastFail = time.Time{}
+	c.lastSuccess = time.Time{}
+	c.failure = 0
+	c.success = 0
+}
+
+// CircuitBreaker implements circuit breaker
+type CircuitBreaker[T any] struct {
+	service          string
+	counter          *Counter
+	state            *State
+	logger           Logger
+	blocked          bool
+	failureThreshold uint32
+	successThreshold uint32
+	callTimeout      time.Duration
+	isSuccessfulFn   func(error) bool
+	sync.Mutex
+}
+
+// New initializes default circuit breaker
+func New[T any](service string, settings ...Option[T]) *CircuitBreaker[T] {
+	cb := &CircuitBreaker[T]{
+		service:          service,
+		counter:          &Counter{resetPeriod: defaultCounterResetPeriod},
+		state:            &State{status: StatusClosed, openPeriod: defaultOpenStatusPeriod},
+		logger:           NoopLogger{},
+		failureThreshold: defaultFailureThreshold,
+		successThreshold: defaultSuccessThreshold,
+	}
+
+	for _, opt := range settings {
+		opt(cb)
+	}
+
+	return cb
+}
+
+// WithFailureThreshold overwrites default value for number of failed request required before
+// circuit breaker will enter open state
+func WithFailureThreshold[T any](t uint32) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.failureThreshold = t
+	}
+}
+
+// WithSuccessThreshold overwrites default value for number of successfuly request required before
+// circuit breaker will enter Closed state from Half Open state
+func WithSuccessThreshold[T any](t uint32) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.successThreshold = t
+	}
+}
+
+// WithCounterResetPeriod defines the period after which counter will reset its failure/success counter
+func WithCounterResetPeriod[T any](t time.Duration) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.counter.resetPeriod = t
+	}
+}
+
+// WithOpenPeriod defines the period for which circuit breaker can stay in open state
+func WithOpenPeriod[T any](t time.Duration) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.state.openPeriod = t
+	}
+}
+
+// WithLogger allows to replace default no-op logger
+func WithLogger[T any](l Logger) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.logger = l
+	}
+}
+
+// WithCallTimeout bounds each call made through ExecContext with a per-call deadline: ExecContext
+// derives a new context with this timeout and passes it to fn.
+func WithCallTimeout[T any](d time.Duration) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.callTimeout = d
+	}
+}
+
+// WithIsSuccessful overrides how an error returned by RequestFunc is classified for the purpose of
+// the circuit breaker's counts. Returning true tells the circuit breaker to treat the call as a
+// success (e.g. for expected errors like HTTP 4xx, context.Canceled or a WithCallTimeout deadline)
+// even though fn returned an error, excluding it from tripping the breaker. The error itself is
+// still returned to the caller. The default treats any non-nil error as a failure.
+func WithIsSuccessful[T any](f func(error) bool) Option[T] {
+	return func(cb *CircuitBreaker[T]) {
+		cb.isSuccessfulFn = f
+	}
+}
+
+// Exec is the wrapper for the request which encapsulates the circuit breaker logic. If fn's error
+// is classified as a failure by IsSuccessful, the zero value of T is returned alongside only the
+// error; otherwise res is returned alongside err, even when err is non-nil (e.g. a
+// context.Canceled that WithIsSuccessful chose to classify as a success).
+func (cb *CircuitBreaker[T]) Exec(fn RequestFunc[T]) (T, error) {
+	if cb.blocked {
+		var zero T
+		return zero, ErrBlocked
+	}
+
+	switch cb.state.Status() {
+	case StatusClosed:
+		res, err := fn()
+		if !cb.isSuccessful(err) {
+			cb.handleError(err)
+			var zero T
+			return zero, err
+		}
+		cb.counter.Success()
+		return res, err
+	case StatusHalfOpen:
+		// half open is intermediate state, where any failure will set back the circuitbreaker into open state
+		// if required number of success responses are received circuitbreaker goes back to the closed state
+		res, err := fn()
+		if !cb.isSuccessful(err) {
+			cb.handleError(err)
+			var zero T
+			return zero, err
+		}
+		if cb.counter.Success() > cb.successThreshold {
+			cb.state.Set(StatusClosed)
+		}
+		return res, err
+	case StatusOpen:
+		var zero T
+		return zero, ErrRequestDisabled
+	}
+	var zero T
+	return zero, nil
+}
+
+// ExecContext is the context-aware counterpart to Exec. It rejects the call immediately if ctx is
+// already done, otherwise propagates ctx into fn (bounded by WithCallTimeout, if configured) and
+// runs it through the same circuit breaker logic as Exec. Timeout and cancellation errors flow
+// through IsSuccessful like any other error, so callers can exclude context.Canceled or a call
+// timeout from tripping the breaker via WithIsSuccessful.
+func (cb *CircuitBreaker[T]) ExecContext(ctx context.Context, fn ContextRequestFunc[T]) (T, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	if cb.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cb.callTimeout)
+		defer cancel()
+	}
+
+	return cb.Exec(func() (T, error) {
+		return fn(ctx)
+	})
+}
+
+func (cb *CircuitBreaker[T]) isSuccessful(err error) bool {
+	if cb.isSuccessfulFn != nil {
+		return cb.isSuccessfulFn(err)
+	}
+	return err == nil
+}
+
+func (cb *CircuitBreaker[T]) handleError(err error) {
+	cb.logger.Error("request failed with ", err)
+	failed := cb.counter.Fail()
+	if failed > cb.failureThreshold || cb.state.Status() == StatusHalfOpen {
+		cb.logger.Info("entering open state for ", cb.state.openPeriod)
+		cb.state.Set(StatusOpen)
+	}
+}
+
+// Reset allows to reset the state to the defaults
+func (cb *CircuitBreaker[T]) Reset() {
+	cb.counter.Reset()
+	cb.state.Reset()
+	cb.Unblock()
+}
+
+// Block blocks all requests
+func (cb *CircuitBreaker[T]) Block() {
+	cb.Lock()
+	cb.blocked = true
+	cb.Unlock()
+}
+
+// Unblock returns circuitbreaker to the normal operation mode
+func (cb *CircuitBreaker[T]) Unblock() {
+	cb.Lock()
+	cb.blocked = false
+	cb.Unlock()
+}
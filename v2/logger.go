@@ -0,0 +1,20 @@
+package v2
+
+// Logger required for logging in circuitbreaker
+type Logger interface {
+	Debug(args ...interface{})
+	Error(args ...interface{})
+	Info(args ...interface{})
+}
+
+// NoopLogger is a default logger used in circuitbreaker it does not do anything
+type NoopLogger struct{}
+
+// Debug ...
+func (NoopLogger) Debug(args ...interface{}) {}
+
+// Error ...
+func (NoopLogger) Error(args ...interface{}) {}
+
+// Info ...
+func (NoopLogger) Info(args ...interface{}) {}